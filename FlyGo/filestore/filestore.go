@@ -0,0 +1,106 @@
+// Package filestore provides a pluggable abstraction over the object
+// storage backends DorsoDepth can upload extracted frames to. Callers
+// obtain a FileStore with New, which picks a concrete implementation
+// based on the FILESTORE_BACKEND environment variable, and thereafter
+// only deal with URIs (s3://, file://, ...) rather than backend-specific
+// clients.
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned by Head when no object exists at the given key.
+var ErrNotExist = errors.New("filestore: object does not exist")
+
+// PutOptions carries the per-object metadata and tags a caller wants
+// attached to an upload, on top of the raw bytes and content type.
+type PutOptions struct {
+	// Metadata is arbitrary user metadata (e.g. video_id, frame_number,
+	// job_id, sha256) stored alongside the object.
+	Metadata map[string]string
+	// Tags are backend-native object tags (e.g. S3 object tagging) used
+	// for lifecycle rules and cost allocation. GCS ignores this; local disk
+	// stores them with its metadata, each key prefixed with "tag:".
+	Tags map[string]string
+}
+
+// PutResult is what a successful Put returns: the URI the object was
+// stored at and a content fingerprint (the backend's ETag, or an
+// equivalent) useful for idempotency checks.
+type PutResult struct {
+	URI  string
+	ETag string
+}
+
+// FileStore is the set of operations the ingester needs from an object
+// storage backend. Every implementation returns and accepts URIs of the
+// form "<scheme>://<bucket-or-root>/<key>" so callers can pass them
+// around (e.g. in a Kafka FrameMessage) without knowing which backend
+// produced them.
+type FileStore interface {
+	// URI returns the URI a given key would be stored/read at, without
+	// touching the backend. Used to report on objects found via Head
+	// without re-uploading them.
+	URI(key string) string
+
+	// Put uploads the contents of body under key with the given metadata
+	// and tags, and returns the URI and ETag it was stored at.
+	Put(ctx context.Context, key string, body io.Reader, contentType string, opts PutOptions) (PutResult, error)
+
+	// Get opens the object stored at key for reading. The caller must
+	// close the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Head returns the user metadata stored on the object at key, or
+	// ErrNotExist if no such object exists. Used by --skip-existing to
+	// decide whether a re-upload can be skipped.
+	Head(ctx context.Context, key string) (map[string]string, error)
+
+	// Presign returns a time-limited URL for downloading the object at
+	// key. Backends that have no notion of presigned URLs (e.g. local
+	// disk) return the URI unchanged.
+	Presign(ctx context.Context, key string, expiry time.Duration) (string, error)
+
+	// Delete removes the object at key.
+	Delete(ctx context.Context, key string) error
+
+	// Walk invokes fn once per object found under prefix. Iteration
+	// stops at the first error returned by fn.
+	Walk(ctx context.Context, prefix string, fn func(key string) error) error
+}
+
+// UploadConfig tunes the concurrent multipart upload behavior of backends
+// that support it (S3, MinIO). A zero value means "use the SDK default".
+type UploadConfig struct {
+	// PartSize is the size in bytes of each part in a multipart upload.
+	PartSize int64
+	// Concurrency is the number of parts uploaded in parallel per object.
+	Concurrency int
+}
+
+// New builds the FileStore selected by the FILESTORE_BACKEND environment
+// variable ("s3", "minio", "gcs", or "local"; defaults to "s3"). cfg tunes
+// upload concurrency for backends that support it and is ignored by those
+// that don't.
+func New(ctx context.Context, cfg UploadConfig) (FileStore, error) {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("FILESTORE_BACKEND")))
+	switch backend {
+	case "", "s3":
+		return NewS3Store(ctx, cfg)
+	case "minio":
+		return NewMinIOStore(ctx, cfg)
+	case "gcs":
+		return NewGCSStore(ctx)
+	case "local":
+		return NewLocalStore()
+	default:
+		return nil, fmt.Errorf("filestore: unknown FILESTORE_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,119 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCSStore stores objects in a Google Cloud Storage bucket and produces
+// "gs://bucket/key" URIs.
+type GCSStore struct {
+	client *storage.Client
+	bucket string
+}
+
+// NewGCSStore builds a GCSStore for the bucket named by GCS_BUCKET using
+// application default credentials.
+func NewGCSStore(ctx context.Context) (*GCSStore, error) {
+	bucket := os.Getenv("GCS_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("filestore: GCS_BUCKET is not set")
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create GCS client: %w", err)
+	}
+
+	return &GCSStore{client: client, bucket: bucket}, nil
+}
+
+func (g *GCSStore) URI(key string) string {
+	return fmt.Sprintf("gs://%s/%s", g.bucket, key)
+}
+
+// Put uploads body to GCS. opts.Tags is ignored: GCS objects have no
+// native per-object tagging equivalent to S3, only user metadata.
+func (g *GCSStore) Put(ctx context.Context, key string, body io.Reader, contentType string, opts PutOptions) (PutResult, error) {
+	w := g.client.Bucket(g.bucket).Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if len(opts.Metadata) > 0 {
+		w.Metadata = opts.Metadata
+	}
+
+	if _, err := io.Copy(w, body); err != nil {
+		w.Close()
+		return PutResult{}, fmt.Errorf("failed to upload %s to GCS: %w", key, err)
+	}
+	if err := w.Close(); err != nil {
+		return PutResult{}, fmt.Errorf("failed to finalize upload of %s to GCS: %w", key, err)
+	}
+	return PutResult{URI: g.URI(key), ETag: w.Attrs().Etag}, nil
+}
+
+func (g *GCSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := g.client.Bucket(g.bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from GCS: %w", key, err)
+	}
+	return r, nil
+}
+
+func (g *GCSStore) Head(ctx context.Context, key string) (map[string]string, error) {
+	attrs, err := g.client.Bucket(g.bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to head %s: %w", key, err)
+	}
+
+	metadata := attrs.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata["etag"] = attrs.Etag
+	return metadata, nil
+}
+
+func (g *GCSStore) Presign(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	url, err := g.client.Bucket(g.bucket).SignedURL(key, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(expiry),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+	return url, nil
+}
+
+func (g *GCSStore) Delete(ctx context.Context, key string) error {
+	if err := g.client.Bucket(g.bucket).Object(key).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete %s from GCS: %w", key, err)
+	}
+	return nil
+}
+
+func (g *GCSStore) Walk(ctx context.Context, prefix string, fn func(key string) error) error {
+	it := g.client.Bucket(g.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		if err := fn(attrs.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
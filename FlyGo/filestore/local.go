@@ -0,0 +1,191 @@
+package filestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStore stores objects under a root directory on local disk and
+// produces "file://<root>/<key>" URIs. It exists mainly to let the
+// ingester be exercised in integration tests without talking to AWS.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore builds a LocalStore rooted at LOCAL_STORE_DIR (defaults
+// to "./filestore-data").
+func NewLocalStore() (*LocalStore, error) {
+	root := os.Getenv("LOCAL_STORE_DIR")
+	if root == "" {
+		root = "./filestore-data"
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create local store root %s: %w", root, err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// path joins key onto root for display purposes (e.g. URI). It does not
+// validate containment; filesystem operations must go through
+// resolvePath instead.
+func (l *LocalStore) path(key string) string {
+	return filepath.Join(l.root, key)
+}
+
+// resolvePath joins key onto root and rejects the result if it would
+// land outside root, so a key like "../../etc/passwd" (or one built from
+// an attacker-controlled job/video ID) can't be used to read, write, or
+// delete files outside the store.
+func (l *LocalStore) resolvePath(key string) (string, error) {
+	rootAbs, err := filepath.Abs(l.root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve store root: %w", err)
+	}
+	joined := filepath.Join(rootAbs, key)
+	if joined != rootAbs && !strings.HasPrefix(joined, rootAbs+string(os.PathSeparator)) {
+		return "", fmt.Errorf("filestore: key %q escapes store root %s", key, l.root)
+	}
+	return joined, nil
+}
+
+func (l *LocalStore) URI(key string) string {
+	return fmt.Sprintf("file://%s", l.path(key))
+}
+
+// metaPath is where Put stashes the metadata/tags for key, since plain
+// files on disk have no attribute store of their own.
+func (l *LocalStore) metaPath(key string) (string, error) {
+	dst, err := l.resolvePath(key)
+	if err != nil {
+		return "", err
+	}
+	return dst + ".meta.json", nil
+}
+
+func (l *LocalStore) Put(ctx context.Context, key string, body io.Reader, contentType string, opts PutOptions) (PutResult, error) {
+	dst, err := l.resolvePath(key)
+	if err != nil {
+		return PutResult{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return PutResult{}, fmt.Errorf("failed to create directory for %s: %w", key, err)
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return PutResult{}, fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(f, io.TeeReader(body, hasher)); err != nil {
+		return PutResult{}, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	etag := hex.EncodeToString(hasher.Sum(nil))
+
+	metadata := map[string]string{}
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range opts.Tags {
+		metadata["tag:"+k] = v
+	}
+	metadata["etag"] = etag
+
+	metaBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return PutResult{}, fmt.Errorf("failed to marshal metadata for %s: %w", key, err)
+	}
+	metaDst, err := l.metaPath(key)
+	if err != nil {
+		return PutResult{}, err
+	}
+	if err := os.WriteFile(metaDst, metaBytes, 0644); err != nil {
+		return PutResult{}, fmt.Errorf("failed to write metadata for %s: %w", key, err)
+	}
+
+	return PutResult{URI: l.URI(key), ETag: etag}, nil
+}
+
+func (l *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	src, err := l.resolvePath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	return f, nil
+}
+
+func (l *LocalStore) Head(ctx context.Context, key string) (map[string]string, error) {
+	metaSrc, err := l.metaPath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(metaSrc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to read metadata for %s: %w", key, err)
+	}
+
+	metadata := map[string]string{}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata for %s: %w", key, err)
+	}
+	return metadata, nil
+}
+
+// Presign has no meaning on local disk; it returns the file:// URI as-is.
+func (l *LocalStore) Presign(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return l.URI(key), nil
+}
+
+func (l *LocalStore) Delete(ctx context.Context, key string) error {
+	dst, err := l.resolvePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	if metaDst, err := l.metaPath(key); err == nil {
+		_ = os.Remove(metaDst)
+	}
+	return nil
+}
+
+func (l *LocalStore) Walk(ctx context.Context, prefix string, fn func(key string) error) error {
+	root, err := l.resolvePath(prefix)
+	if err != nil {
+		return err
+	}
+	rootAbs, err := filepath.Abs(l.root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve store root: %w", err)
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".meta.json") {
+			return nil
+		}
+		key, relErr := filepath.Rel(rootAbs, path)
+		if relErr != nil {
+			return relErr
+		}
+		return fn(key)
+	})
+}
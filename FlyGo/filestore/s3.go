@@ -0,0 +1,200 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Store stores objects in an AWS S3 bucket and produces "s3://bucket/key"
+// URIs. MinIO reuses this implementation with a custom endpoint resolver
+// and path-style addressing; see NewMinIOStore. Uploads go through an
+// s3manager.Uploader so large frames are split into concurrent multipart
+// parts instead of one blocking PutObject call.
+type S3Store struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	bucket   string
+}
+
+func newUploader(client *s3.Client, cfg UploadConfig) *manager.Uploader {
+	return manager.NewUploader(client, func(u *manager.Uploader) {
+		if cfg.PartSize > 0 {
+			u.PartSize = cfg.PartSize
+		}
+		if cfg.Concurrency > 0 {
+			u.Concurrency = cfg.Concurrency
+		}
+	})
+}
+
+// NewS3Store builds an S3Store for the bucket named by S3_BUCKET using the
+// default AWS SDK credential chain.
+func NewS3Store(ctx context.Context, cfg UploadConfig) (*S3Store, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("filestore: S3_BUCKET is not set")
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg)
+	return &S3Store{client: client, uploader: newUploader(client, cfg), bucket: bucket}, nil
+}
+
+// NewMinIOStore builds an S3Store pointed at a MinIO (or other
+// S3-compatible) endpoint using path-style addressing. Configured via
+// MINIO_ENDPOINT, MINIO_BUCKET, MINIO_REGION (defaults to "us-east-1").
+func NewMinIOStore(ctx context.Context, cfg UploadConfig) (*S3Store, error) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		return nil, fmt.Errorf("filestore: MINIO_ENDPOINT is not set")
+	}
+	bucket := os.Getenv("MINIO_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("filestore: MINIO_BUCKET is not set")
+	}
+	region := os.Getenv("MINIO_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = true
+	})
+
+	return &S3Store{client: client, uploader: newUploader(client, cfg), bucket: bucket}, nil
+}
+
+func (s *S3Store) URI(key string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, key)
+}
+
+// tagsToQueryString encodes tags in the "k1=v1&k2=v2" form S3 object
+// tagging expects.
+func tagsToQueryString(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, contentType string, opts PutOptions) (PutResult, error) {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = opts.Metadata
+	}
+	if tagging := tagsToQueryString(opts.Tags); tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+
+	out, err := s.uploader.Upload(ctx, input)
+	if err != nil {
+		return PutResult{}, fmt.Errorf("failed to upload %s to S3: %w", key, err)
+	}
+	return PutResult{URI: s.URI(key), ETag: strings.Trim(aws.ToString(out.ETag), "\"")}, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from S3: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3Store) Head(ctx context.Context, key string) (map[string]string, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to head %s: %w", key, err)
+	}
+
+	metadata := out.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata["etag"] = strings.Trim(aws.ToString(out.ETag), "\"")
+	return metadata, nil
+}
+
+func (s *S3Store) Presign(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	req, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+	return req.URL, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from S3: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Walk(ctx context.Context, prefix string, fn func(key string) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			if err := fn(aws.ToString(obj.Key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
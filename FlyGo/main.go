@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
@@ -19,13 +24,21 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/segmentio/kafka-go"
+
+	"github.com/shubh-man007/DorsoDepth/FlyGo/filestore"
 )
 
+// frameTopic is the Kafka topic frame messages are relayed to once their
+// outbox row is published, and the topic downstream frame processors
+// consume from.
+const frameTopic = "frames-to-process"
+
 type FrameMessage struct {
 	VideoID     string `json:"video_id"`
 	FrameID     string `json:"frame_id"`
 	FrameNumber int    `json:"frame_number"`
 	S3Path      string `json:"s3_path"`
+	TotalFrames int    `json:"total_frames,omitempty"`
 }
 
 func extractFrames(videoPath, outputDir string, fps int) error {
@@ -43,42 +56,99 @@ func extractFrames(videoPath, outputDir string, fps int) error {
 	return cmd.Run()
 }
 
-func uploadFileToS3(ctx context.Context, client *s3.Client, bucket, key, filePath string) error {
+// frameTags are the S3 object tags attached to every uploaded frame so
+// operators can drive lifecycle rules and cost allocation without a DB
+// lookup.
+var frameTags = map[string]string{"env": "prod", "pipeline": "dorsodepth"}
+
+// sha256File hashes the contents of filePath.
+func sha256File(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", fmt.Errorf("failed to hash file %s: %w", filePath, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// uploadFrameFile uploads a single extracted frame through the configured
+// FileStore and returns the URI it was stored at.
+func uploadFrameFile(ctx context.Context, store filestore.FileStore, key, filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+		return "", fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
 	defer file.Close()
 
-	_, err = client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(bucket),
-		Key:         aws.String(key),
-		Body:        file,
-		ContentType: aws.String("image/jpeg"),
-	})
+	result, err := store.Put(ctx, key, file, "image/jpeg", filestore.PutOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to upload %s to S3: %w", filePath, err)
+		return "", err
 	}
-	return nil
+	return result.URI, nil
 }
 
-func uploadDirToS3(ctx context.Context, client *s3.Client, bucket, prefix, dir string) error {
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+// uploadFrame tags and uploads filePath, attaching video_id/frame_number/
+// job_id as object metadata, and returns its SHA-256 fingerprint and the
+// store's ETag. When skipExisting is set it hashes filePath up front and
+// HEADs the object first, skipping the upload if the stored sha256
+// already matches; otherwise the fingerprint is derived from a single
+// read of the file via an io.TeeReader wrapped around the upload body,
+// so a frame is never read off disk twice just to compute its hash.
+func uploadFrame(ctx context.Context, store filestore.FileStore, key, filePath, videoID string, frameNumber int, jobID string, skipExisting bool) (result filestore.PutResult, sha256Hex string, skipped bool, err error) {
+	if skipExisting {
+		sha256Hex, err = sha256File(filePath)
 		if err != nil {
-			return err
+			return filestore.PutResult{}, "", false, err
 		}
-		if !info.IsDir() {
-			key := filepath.Join(prefix, info.Name())
-			fmt.Printf("Uploading %s to s3://%s/%s...\n", path, bucket, key)
-			if err := uploadFileToS3(ctx, client, bucket, key, path); err != nil {
-				log.Printf("Failed to upload %s: %v", path, err)
-			} else {
-				fmt.Printf("Successfully uploaded %s to s3://%s/%s\n", path, bucket, key)
-			}
+
+		meta, headErr := store.Head(ctx, key)
+		if headErr == nil && meta["sha256"] == sha256Hex {
+			return filestore.PutResult{URI: store.URI(key), ETag: meta["etag"]}, sha256Hex, true, nil
 		}
-		return nil
+		if headErr != nil && !errors.Is(headErr, filestore.ErrNotExist) {
+			return filestore.PutResult{}, "", false, fmt.Errorf("failed to head %s: %w", key, headErr)
+		}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return filestore.PutResult{}, "", false, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	metadata := map[string]string{
+		"video_id":     videoID,
+		"frame_number": strconv.Itoa(frameNumber),
+		"job_id":       jobID,
+	}
+
+	var body io.Reader = file
+	hasher := sha256.New()
+	if sha256Hex == "" {
+		// Not already hashed above (skipExisting is off): derive the
+		// fingerprint from the same pass that streams the upload instead
+		// of a separate full read.
+		body = io.TeeReader(file, hasher)
+	} else {
+		metadata["sha256"] = sha256Hex
+	}
+
+	result, err = store.Put(ctx, key, body, "image/jpeg", filestore.PutOptions{
+		Metadata: metadata,
+		Tags:     frameTags,
 	})
-	return err
+	if err != nil {
+		return filestore.PutResult{}, "", false, fmt.Errorf("failed to upload %s: %w", filePath, err)
+	}
+	if sha256Hex == "" {
+		sha256Hex = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return result, sha256Hex, false, nil
 }
 
 func connectDB() (*pgxpool.Pool, error) {
@@ -86,51 +156,188 @@ func connectDB() (*pgxpool.Pool, error) {
 	return pgxpool.New(context.Background(), dbURL)
 }
 
-func insertVideo(db *pgxpool.Pool, videoID, name string, length, fps int) error {
+func insertVideo(db *pgxpool.Pool, videoID, name string, length, fps, width, height int, codec string, bitrate int64, totalFrames int) error {
 	_, err := db.Exec(context.Background(),
-		"INSERT INTO videos (video_id, name, length, fps) VALUES ($1, $2, $3, $4)",
-		videoID, name, length, fps)
+		"INSERT INTO videos (video_id, name, length, fps, width, height, codec, bitrate, total_frames) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		videoID, name, length, fps, width, height, codec, bitrate, totalFrames)
 	return err
 }
 
-func insertFrame(db *pgxpool.Pool, frameID, videoID string, frameNumber int, s3Path, status string) error {
-	_, err := db.Exec(context.Background(),
-		"INSERT INTO frames (frame_id, video_id, frame_number, s3_path, status) VALUES ($1, $2, $3, $4, $5)",
-		frameID, videoID, frameNumber, s3Path, status)
-	return err
+// frameRow is a single row destined for the frames table, batched up so it
+// can go through insertFramesWithOutbox instead of one INSERT per frame.
+// sha256 and etag make re-uploads idempotent and enable dedup detection.
+type frameRow struct {
+	frameID     string
+	videoID     string
+	frameNumber int
+	s3Path      string
+	status      string
+	sha256      string
+	etag        string
 }
 
-func sendKafkaMessage(broker, topic string, message interface{}) error {
-	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:  []string{broker},
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+// newKafkaWriter builds a single Kafka writer meant to be reused for every
+// message published during a run, instead of opening and closing a new
+// connection per frame.
+func newKafkaWriter(broker, topic string, batchSize int) *kafka.Writer {
+	return kafka.NewWriter(kafka.WriterConfig{
+		Brokers:   []string{broker},
+		Topic:     topic,
+		Balancer:  &kafka.LeastBytes{},
+		BatchSize: batchSize,
 	})
-	defer w.Close()
+}
 
-	msgBytes, err := json.Marshal(message)
+// uploadedFrame is what an upload worker hands back for a single
+// extracted frame once it has been written to the FileStore.
+type uploadedFrame struct {
+	frameID     string
+	frameNumber int
+	s3Path      string
+	sha256      string
+	etag        string
+}
+
+// runFileMode extracts frames from a local video file, uploads them to the
+// configured FileStore through a bounded worker pool, and flushes the
+// resulting frame rows in batches of batchSize instead of one at a time.
+// Each batch is written through insertFramesWithOutbox, so the frame row
+// and its Kafka message either both land or neither does; a separate
+// outbox relay goroutine (see runOutboxRelay) is what actually publishes
+// them. When skipExisting is set, frames whose content already exists
+// under the same key (matched by sha256) are not re-uploaded. It is used
+// directly by the "file" CLI mode and reused by worker mode to process
+// one job at a time.
+func runFileMode(ctx context.Context, db *pgxpool.Pool, store filestore.FileStore, videoPath, outputDir string, fps, uploadConcurrency, batchSize int, jobID string, skipExisting bool) error {
+	videoID := jobID
+	videoName := filepath.Base(videoPath)
+
+	probe, err := probeVideo(videoPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to probe video: %w", err)
+	}
+	videoLength := int(math.Round(probe.DurationSeconds))
+
+	if err := extractFrames(videoPath, outputDir, fps); err != nil {
+		return fmt.Errorf("error extracting frames: %w", err)
+	}
+	fmt.Println("Frame extraction complete.")
+
+	var framePaths []string
+	err = filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			framePaths = append(framePaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list extracted frames: %w", err)
 	}
 
-	return w.WriteMessages(context.Background(),
-		kafka.Message{
-			Value: msgBytes,
-		},
-	)
+	// ffprobe's nb_frames is commonly absent ("N/A") unless it was run with
+	// -count_frames, which is expensive; fall back to the number of frames
+	// we actually extracted rather than recording a bogus 0 that would make
+	// downstream completion tracking divide by zero.
+	totalFrames := probe.TotalFrames
+	if totalFrames == 0 {
+		totalFrames = len(framePaths)
+	}
+
+	if err := insertVideo(db, videoID, videoName, videoLength, fps, probe.Width, probe.Height, probe.Codec, probe.BitRate, totalFrames); err != nil {
+		return fmt.Errorf("failed to insert video: %w", err)
+	}
+	fmt.Printf("Inserted video metadata: %s (%dx%d, %s, %d frames)\n", videoID, probe.Width, probe.Height, probe.Codec, totalFrames)
+
+	// Pre-allocate a frame ID for each extracted frame up front instead of
+	// minting one per upload goroutine. The count is the number of extracted
+	// frame files; the probed frame count is not used here.
+	frameIDs := make([]string, len(framePaths))
+	for i := range frameIDs {
+		frameIDs[i] = uuid.New().String()
+	}
+
+	prefix := fmt.Sprintf("frames/%s", videoID)
+	uploaded := make(chan uploadedFrame, len(framePaths))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(uploadConcurrency)
+	for i, framePath := range framePaths {
+		i, framePath := i, framePath
+		g.Go(func() error {
+			name := filepath.Base(framePath)
+			key := filepath.Join(prefix, name)
+
+			var frameNumber int
+			fmt.Sscanf(name, "frame_%d.jpg", &frameNumber)
+
+			result, sha256Hex, skipped, err := uploadFrame(gctx, store, key, framePath, videoID, frameNumber, jobID, skipExisting)
+			if err != nil {
+				log.Printf("Failed to upload %s: %v", framePath, err)
+				return nil
+			}
+			if skipped {
+				fmt.Printf("Skipping %s: already uploaded with matching sha256\n", framePath)
+			}
+
+			uploaded <- uploadedFrame{
+				frameID:     frameIDs[i],
+				frameNumber: frameNumber,
+				s3Path:      result.URI,
+				sha256:      sha256Hex,
+				etag:        result.ETag,
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("frame upload pool failed: %w", err)
+	}
+	close(uploaded)
+
+	var frames []uploadedFrame
+	for f := range uploaded {
+		frames = append(frames, f)
+	}
+
+	for start := 0; start < len(frames); start += batchSize {
+		end := start + batchSize
+		if end > len(frames) {
+			end = len(frames)
+		}
+		batch := frames[start:end]
+
+		rows := make([]frameRow, len(batch))
+		msgs := make([]FrameMessage, len(batch))
+		for i, f := range batch {
+			rows[i] = frameRow{frameID: f.frameID, videoID: videoID, frameNumber: f.frameNumber, s3Path: f.s3Path, status: "pending", sha256: f.sha256, etag: f.etag}
+			msgs[i] = FrameMessage{VideoID: videoID, FrameID: f.frameID, FrameNumber: f.frameNumber, S3Path: f.s3Path, TotalFrames: totalFrames}
+		}
+
+		if err := insertFramesWithOutbox(ctx, db, rows, frameTopic, msgs); err != nil {
+			log.Printf("Failed to insert frame batch: %v", err)
+			continue
+		}
+		fmt.Printf("Inserted %d frames (%d-%d of %d), queued for outbox relay\n", len(batch), start, end-1, len(frames))
+	}
+
+	return nil
 }
 
 func main() {
 	godotenv.Load()
-	if len(os.Args) < 5 {
-		fmt.Println("Usage: go run main.go <video_path> <output_dir> <fps> <job_id>")
-		os.Exit(1)
-	}
-	videoPath := os.Args[1]
-	outputDir := os.Args[2]
-	fps := 1
-	fmt.Sscanf(os.Args[3], "%d", &fps)
-	jobID := os.Args[4]
+
+	mode := flag.String("mode", "file", "ingestion mode: file (extract a local video), hls (segment a stream to S3), live-frames (publish frames from a live stream to Kafka), or worker (long-running, consumes jobs from --ingest-topic)")
+	uploadConcurrency := flag.Int("upload-concurrency", 4, "number of frames uploaded to the FileStore in parallel")
+	partSize := flag.Int64("part-size", 5*1024*1024, "size in bytes of each multipart upload part (S3/MinIO backends only)")
+	kafkaBatchSize := flag.Int("kafka-batch-size", 100, "number of frame rows/messages flushed to Postgres and Kafka per batch")
+	skipExisting := flag.Bool("skip-existing", false, "HEAD each frame before uploading and skip it if the stored sha256 already matches (file and worker modes only)")
+	kafkaBroker := flag.String("kafka-broker", "localhost:9092", "Kafka broker address")
+	ingestTopic := flag.String("ingest-topic", "videos-to-ingest", "topic worker mode consumes video jobs from")
+	outboxPollInterval := flag.Duration("outbox-poll-interval", 2*time.Second, "how often the outbox relay checks for unpublished frame messages")
+	flag.Parse()
 
 	db, err := connectDB()
 	if err != nil {
@@ -138,76 +345,66 @@ func main() {
 	}
 	defer db.Close()
 
-	videoID := jobID
-	videoName := filepath.Base(videoPath)
-	videoLength := 0
+	ctx := context.Background()
+	store, err := filestore.New(ctx, filestore.UploadConfig{PartSize: *partSize, Concurrency: *uploadConcurrency})
+	if err != nil {
+		log.Fatalf("Failed to initialize filestore backend: %v", err)
+	}
+
+	// relayWriter has no default topic: it publishes whatever topic each
+	// outbox row was tagged with, rather than the single topic a regular
+	// writer is pinned to.
+	relayWriter := newKafkaWriter(*kafkaBroker, "", *kafkaBatchSize)
+	defer relayWriter.Close()
+	go func() {
+		if err := runOutboxRelay(ctx, db, relayWriter, *outboxPollInterval); err != nil {
+			log.Printf("outbox relay stopped: %v", err)
+		}
+	}()
 
-	if err := insertVideo(db, videoID, videoName, videoLength, fps); err != nil {
-		log.Fatalf("Failed to insert video: %v", err)
+	if *mode == "worker" {
+		if err := runWorkerMode(ctx, db, store, *kafkaBroker, *ingestTopic, *uploadConcurrency, *kafkaBatchSize, *skipExisting); err != nil {
+			log.Fatalf("Worker mode failed: %v", err)
+		}
+		return
 	}
-	fmt.Printf("Inserted video metadata: %s\n", videoID)
 
-	if err := extractFrames(videoPath, outputDir, fps); err != nil {
-		fmt.Printf("Error extracting frames: %v\n", err)
+	args := flag.Args()
+	if len(args) < 4 {
+		fmt.Println("Usage: go run main.go [--mode=file|hls|live-frames|worker] <video_path_or_url> <output_dir> <fps> <job_id>")
 		os.Exit(1)
 	}
-	fmt.Println("Frame extraction complete.")
+	input := args[0]
+	outputDir := args[1]
+	fps := 1
+	fmt.Sscanf(args[2], "%d", &fps)
+	jobID := args[3]
 
-	bucket := os.Getenv("S3_BUCKET")
-	if bucket == "" {
-		bucket = "your-s3-bucket-name"
+	switch *mode {
+	case "file":
+		err = runFileMode(ctx, db, store, input, outputDir, fps, *uploadConcurrency, *kafkaBatchSize, jobID, *skipExisting)
+	case "hls":
+		err = runHLSIngest(ctx, store, jobID, input, outputDir)
+	case "live-frames":
+		err = runLiveFrameIngest(ctx, db, store, jobID, input, fps)
+	default:
+		log.Fatalf("Unknown --mode %q (expected file, hls, live-frames, or worker)", *mode)
 	}
-	prefix := fmt.Sprintf("frames/%s", videoID)
-
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		log.Fatalf("unable to load AWS SDK config, %v", err)
+		log.Fatalf("Ingestion failed: %v", err)
 	}
-	client := s3.NewFromConfig(cfg)
 
-	err = filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			key := filepath.Join(prefix, info.Name())
-			fmt.Printf("Uploading %s to s3://%s/%s...\n", path, bucket, key)
-			if err := uploadFileToS3(ctx, client, bucket, key, path); err != nil {
-				log.Printf("Failed to upload %s: %v", path, err)
-			} else {
-				fmt.Printf("Successfully uploaded %s to s3://%s/%s\n", path, bucket, key)
-				frameID := uuid.New().String()
-				var frameNumber int
-				fmt.Sscanf(info.Name(), "frame_%d.jpg", &frameNumber)
-				s3Path := fmt.Sprintf("s3://%s/%s", bucket, key)
-				if err := insertFrame(db, frameID, videoID, frameNumber, s3Path, "pending"); err != nil {
-					log.Printf("Failed to insert frame: %v", err)
-				} else {
-					fmt.Printf("Inserted frame metadata: %s\n", frameID)
-					// Send Kafka message
-					kafkaBroker := "localhost:9092"
-					kafkaTopic := "frames-to-process"
-					frameMsg := FrameMessage{
-						VideoID:     videoID,
-						FrameID:     frameID,
-						FrameNumber: frameNumber,
-						S3Path:      s3Path,
-					}
-					if err := sendKafkaMessage(kafkaBroker, kafkaTopic, frameMsg); err != nil {
-						log.Printf("Failed to send Kafka message: %v", err)
-					} else {
-						fmt.Printf("Sent Kafka message for frame %d\n", frameNumber)
-					}
-				}
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		log.Fatalf("Error uploading frames to S3: %v", err)
+	// file/hls/live-frames are one-shot invocations: unlike worker mode,
+	// nothing keeps the process alive long enough for the background
+	// runOutboxRelay ticker to necessarily fire before we exit. Drain
+	// whatever this run queued so we don't return with frame rows
+	// committed but their Kafka messages still stuck in the outbox.
+	if err := drainOutbox(ctx, db, relayWriter); err != nil {
+		log.Fatalf("Failed to drain outbox: %v", err)
 	}
-	fmt.Println("All frames uploaded to S3 and metadata inserted.")
+	fmt.Println("Ingestion complete.")
 }
 
 // go run main.go input/input2.mp4 output 1
+// go run main.go --mode=live-frames rtsp://camera.local/stream output 5 job-123
+// go run main.go --mode=worker --ingest-topic=videos-to-ingest
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/segmentio/kafka-go"
+)
+
+// outboxRelayBatchSize caps how many outbox rows a single relay tick
+// claims, so one slow publish doesn't hold a lock on the entire table.
+const outboxRelayBatchSize = 200
+
+// insertFramesWithOutbox writes the frame rows and their corresponding
+// Kafka messages in a single transaction: frames go to the frames table
+// and msgs go to the outbox table, tagged with outboxTopic. Neither side
+// is visible to the rest of the system unless both commit, which closes
+// the gap where a frame row lands but its Kafka message never does (or
+// the reverse). A separate goroutine (see runOutboxRelay) is responsible
+// for actually publishing outbox rows and deleting them once acked.
+func insertFramesWithOutbox(ctx context.Context, db *pgxpool.Pool, rows []frameRow, outboxTopic string, msgs []FrameMessage) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if len(rows) != len(msgs) {
+		return fmt.Errorf("insertFramesWithOutbox: got %d frame rows but %d messages", len(rows), len(msgs))
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	frameCopyRows := make([][]interface{}, len(rows))
+	for i, r := range rows {
+		frameCopyRows[i] = []interface{}{r.frameID, r.videoID, r.frameNumber, r.s3Path, r.status, r.sha256, r.etag}
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"frames"},
+		[]string{"frame_id", "video_id", "frame_number", "s3_path", "status", "sha256", "etag"},
+		pgx.CopyFromRows(frameCopyRows),
+	); err != nil {
+		return fmt.Errorf("failed to insert frame rows: %w", err)
+	}
+
+	outboxCopyRows := make([][]interface{}, len(msgs))
+	for i, m := range msgs {
+		payload, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal frame message: %w", err)
+		}
+		outboxCopyRows[i] = []interface{}{outboxTopic, payload}
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"outbox"},
+		[]string{"topic", "payload"},
+		pgx.CopyFromRows(outboxCopyRows),
+	); err != nil {
+		return fmt.Errorf("failed to insert outbox rows: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+	return nil
+}
+
+// runOutboxRelay polls the outbox table every pollInterval and publishes
+// pending rows to Kafka, deleting each row only after WriteMessages
+// confirms it was accepted (at-least-once: a relay crash between publish
+// and delete can redeliver a message, but it can never lose one). It runs
+// until ctx is cancelled. relayWriter must be configured without a
+// default topic, since each outbox row carries its own.
+func runOutboxRelay(ctx context.Context, db *pgxpool.Pool, relayWriter *kafka.Writer, pollInterval time.Duration) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := relayOutboxBatch(ctx, db, relayWriter); err != nil {
+				log.Printf("outbox relay: %v", err)
+			}
+		}
+	}
+}
+
+// maxDrainIterations bounds drainOutbox so it can't block forever. The
+// outbox table is shared by every process talking to this Postgres
+// instance (it has no job_id to scope by), so if a concurrent
+// long-running worker is inserting rows as fast as drainOutbox can
+// publish them, the table may never look empty from this process's
+// point of view. Each iteration relays up to outboxRelayBatchSize rows,
+// so this caps drainOutbox at 200,000 rows before it
+// gives up and lets main() exit anyway.
+const maxDrainIterations = 1000
+
+// drainOutbox calls relayOutboxBatch repeatedly until the outbox is empty,
+// an error occurs, or maxDrainIterations is reached. One-shot modes (file,
+// live-frames once ffmpeg exits) insert their last frame batch and then
+// return immediately; without this, those rows could sit unpublished
+// until a ticker fires that the process never lives to see, leaving
+// frame rows in Postgres with no corresponding Kafka message. Worker mode
+// doesn't call this: it runs until a job fails, and rows it leaves in the
+// outbox stay there for the next process's runOutboxRelay to publish.
+func drainOutbox(ctx context.Context, db *pgxpool.Pool, relayWriter *kafka.Writer) error {
+	for i := 0; i < maxDrainIterations; i++ {
+		n, err := relayOutboxBatch(ctx, db, relayWriter)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+	log.Printf("outbox drain: gave up after %d iterations, outbox may still hold unpublished rows (check for a concurrent worker feeding it)", maxDrainIterations)
+	return nil
+}
+
+// relayOutboxBatch claims up to outboxRelayBatchSize rows with
+// FOR UPDATE SKIP LOCKED (so multiple relay instances can run
+// concurrently without double-publishing), publishes them, and deletes
+// them within the same transaction that holds the row locks. It returns
+// the number of rows relayed, so callers can tell whether the outbox is
+// now empty.
+func relayOutboxBatch(ctx context.Context, db *pgxpool.Pool, relayWriter *kafka.Writer) (int, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin relay transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		"SELECT id, topic, payload FROM outbox ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED",
+		outboxRelayBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query outbox: %w", err)
+	}
+
+	type pendingMessage struct {
+		id      int64
+		topic   string
+		payload []byte
+	}
+	var pending []pendingMessage
+	for rows.Next() {
+		var m pendingMessage
+		if err := rows.Scan(&m.id, &m.topic, &m.payload); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox row: %w", err)
+		}
+		pending = append(pending, m)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, fmt.Errorf("failed to read outbox rows: %w", rows.Err())
+	}
+	if len(pending) == 0 {
+		return 0, tx.Commit(ctx)
+	}
+
+	kafkaMsgs := make([]kafka.Message, len(pending))
+	ids := make([]int64, len(pending))
+	for i, m := range pending {
+		kafkaMsgs[i] = kafka.Message{Topic: m.topic, Value: m.payload}
+		ids[i] = m.id
+	}
+
+	if err := relayWriter.WriteMessages(ctx, kafkaMsgs...); err != nil {
+		return 0, fmt.Errorf("failed to publish outbox batch: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM outbox WHERE id = ANY($1)", ids); err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox rows: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit relay transaction: %w", err)
+	}
+	return len(pending), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// videoProbe is the subset of ffprobe's output the ingester cares about.
+type videoProbe struct {
+	DurationSeconds float64
+	Width           int
+	Height          int
+	Codec           string
+	BitRate         int64
+	TotalFrames     int
+}
+
+// ffprobeOutput mirrors the JSON shape of
+// `ffprobe -print_format json -show_format -show_streams`.
+type ffprobeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		NbFrames  string `json:"nb_frames"`
+	} `json:"streams"`
+}
+
+// probeVideo runs ffprobe against videoPath and extracts duration,
+// resolution, codec, bitrate, and frame count so callers no longer have to
+// hardcode them.
+func probeVideo(videoPath string) (videoProbe, error) {
+	cmd := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", videoPath)
+	out, err := cmd.Output()
+	if err != nil {
+		return videoProbe{}, fmt.Errorf("failed to run ffprobe on %s: %w", videoPath, err)
+	}
+
+	var probed ffprobeOutput
+	if err := json.Unmarshal(out, &probed); err != nil {
+		return videoProbe{}, fmt.Errorf("failed to parse ffprobe output for %s: %w", videoPath, err)
+	}
+
+	var videoStream *struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		NbFrames  string `json:"nb_frames"`
+	}
+	for i := range probed.Streams {
+		if probed.Streams[i].CodecType == "video" {
+			videoStream = &probed.Streams[i]
+			break
+		}
+	}
+	if videoStream == nil {
+		return videoProbe{}, fmt.Errorf("no video stream found in %s", videoPath)
+	}
+
+	duration, _ := strconv.ParseFloat(probed.Format.Duration, 64)
+	bitRate, _ := strconv.ParseInt(probed.Format.BitRate, 10, 64)
+	totalFrames, _ := strconv.Atoi(videoStream.NbFrames)
+
+	return videoProbe{
+		DurationSeconds: duration,
+		Width:           videoStream.Width,
+		Height:          videoStream.Height,
+		Codec:           videoStream.CodecName,
+		BitRate:         bitRate,
+		TotalFrames:     totalFrames,
+	}, nil
+}
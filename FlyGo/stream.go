@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shubh-man007/DorsoDepth/FlyGo/filestore"
+)
+
+// runHLSIngest pipes an HTTP/RTSP stream into ffmpeg, segments it into HLS
+// chunks written to outputDir, and uploads a chunk to store each time
+// fsnotify reports it was created or written to, so a chunk can be
+// uploaded several times, the first before ffmpeg has finished writing
+// it. It runs until ffmpeg exits (e.g. the stream
+// ends or the process is killed), so it is meant to be used as a
+// long-running job rather than a one-shot CLI invocation.
+func runHLSIngest(ctx context.Context, store filestore.FileStore, jobID, inputURL, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	playlistPath := filepath.Join(outputDir, "stream.m3u8")
+	segmentPattern := filepath.Join(outputDir, "segment_%05d.ts")
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-re", "-i", inputURL,
+		"-c", "copy",
+		"-f", "hls",
+		"-hls_time", "4",
+		"-hls_list_size", "0",
+		"-hls_segment_filename", segmentPattern,
+		playlistPath,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Printf("Running command: %v\n", cmd.Args)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	prefix := fmt.Sprintf("hls/%s", jobID)
+	watchErr := watchAndUpload(ctx, store, outputDir, prefix, func(name string) bool {
+		return strings.HasSuffix(name, ".ts") || strings.HasSuffix(name, ".m3u8")
+	})
+
+	waitErr := cmd.Wait()
+	if waitErr != nil {
+		return fmt.Errorf("ffmpeg exited with error: %w", waitErr)
+	}
+	return watchErr
+}
+
+// runLiveFrameIngest pipes an HTTP/RTSP stream into ffmpeg, extracts frames
+// at fps on the fly, and inserts each one through insertFramesWithOutbox
+// as soon as it is written, so downstream workers can process a live
+// camera feed frame by frame rather than waiting for a complete file. As
+// with file mode, the frame row and its Kafka message are written in the
+// same transaction; a separate outbox relay goroutine does the actual
+// publish.
+func runLiveFrameIngest(ctx context.Context, db *pgxpool.Pool, store filestore.FileStore, jobID, inputURL string, fps int) error {
+	outputDir := filepath.Join(os.TempDir(), fmt.Sprintf("dorsodepth-live-%s", jobID))
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	outputPattern := filepath.Join(outputDir, "frame_%08d.jpg")
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-re", "-i", inputURL,
+		"-vf", fmt.Sprintf("fps=%d", fps),
+		outputPattern,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Printf("Running command: %v\n", cmd.Args)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	prefix := fmt.Sprintf("frames/%s", jobID)
+	// fsnotify reports several events per frame file; only the first
+	// inserts a frame row.
+	inserted := map[string]bool{}
+	watchErr := watchAndUpload(ctx, store, outputDir, prefix, func(name string) bool {
+		return strings.HasSuffix(name, ".jpg")
+	}, func(key, s3Path string) {
+		if inserted[key] {
+			return
+		}
+		inserted[key] = true
+		frameID := uuid.New().String()
+		var frameNumber int
+		fmt.Sscanf(filepath.Base(key), "frame_%d.jpg", &frameNumber)
+
+		row := frameRow{frameID: frameID, videoID: jobID, frameNumber: frameNumber, s3Path: s3Path, status: "pending"}
+		frameMsg := FrameMessage{
+			VideoID:     jobID,
+			FrameID:     frameID,
+			FrameNumber: frameNumber,
+			S3Path:      s3Path,
+		}
+		if err := insertFramesWithOutbox(ctx, db, []frameRow{row}, frameTopic, []FrameMessage{frameMsg}); err != nil {
+			fmt.Printf("Failed to insert frame: %v\n", err)
+		}
+	})
+
+	waitErr := cmd.Wait()
+	if waitErr != nil {
+		return fmt.Errorf("ffmpeg exited with error: %w", waitErr)
+	}
+	return watchErr
+}
+
+// watchAndUpload watches dir with fsnotify and uploads every file that
+// matches accept as soon as fsnotify reports it was written, invoking each
+// onUpload callback (if any) with the uploaded key and URI. It returns once
+// ctx is cancelled.
+func watchAndUpload(ctx context.Context, store filestore.FileStore, dir, prefix string, accept func(name string) bool, onUpload ...func(key, uri string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			name := filepath.Base(event.Name)
+			if !accept(name) {
+				continue
+			}
+			key := filepath.Join(prefix, name)
+			uri, err := uploadFrameFile(ctx, store, key, event.Name)
+			if err != nil {
+				fmt.Printf("Failed to upload %s: %v\n", event.Name, err)
+				continue
+			}
+			fmt.Printf("Uploaded %s to %s\n", event.Name, uri)
+			for _, cb := range onUpload {
+				cb(key, uri)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Printf("Watcher error: %v\n", err)
+		}
+	}
+}
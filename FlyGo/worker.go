@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/shubh-man007/DorsoDepth/FlyGo/filestore"
+)
+
+// VideoJob is the message shape worker mode expects on its ingest topic: a
+// video to fetch plus the job ID to tag every frame it produces with.
+type VideoJob struct {
+	VideoURI  string `json:"video_uri"`
+	JobID     string `json:"job_id"`
+	OutputDir string `json:"output_dir,omitempty"`
+	FPS       int    `json:"fps,omitempty"`
+}
+
+// jobIDPattern restricts job IDs to characters that are safe to embed in
+// filesystem paths and object keys. JobID arrives verbatim from an
+// external Kafka message, so without this a value like
+// "../../../../tmp/evil" could be used to escape the job's scratch
+// directory or a local FileStore's root.
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// resolveJobOutputDir validates jobID and returns the scratch directory a
+// job's frames should be extracted to, rejecting any outputDir override
+// that would land outside os.TempDir().
+func resolveJobOutputDir(jobID, outputDir string) (string, error) {
+	if jobID == "" || !jobIDPattern.MatchString(jobID) {
+		return "", fmt.Errorf("invalid job_id %q", jobID)
+	}
+
+	if outputDir == "" {
+		outputDir = filepath.Join(os.TempDir(), fmt.Sprintf("dorsodepth-job-%s", jobID))
+	}
+
+	tempRoot, err := filepath.Abs(os.TempDir())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve temp dir: %w", err)
+	}
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve output_dir: %w", err)
+	}
+	if absOutputDir != tempRoot && !strings.HasPrefix(absOutputDir, tempRoot+string(os.PathSeparator)) {
+		return "", fmt.Errorf("output_dir %q escapes %s", outputDir, tempRoot)
+	}
+	return absOutputDir, nil
+}
+
+// runWorkerMode turns the ingester into a long-running service that
+// consumes VideoJob messages from ingestTopic and runs the same
+// extract/upload/publish pipeline as file mode for each one. A job's
+// Kafka offset is only committed after runFileMode returns successfully,
+// so a crash or failure mid-video leaves the message uncommitted and it
+// gets redelivered and retried rather than silently dropped. A failed job
+// makes runWorkerMode return its error instead of moving on, because
+// committing a later message would also commit past the failed one.
+func runWorkerMode(ctx context.Context, db *pgxpool.Pool, store filestore.FileStore, broker, ingestTopic string, uploadConcurrency, batchSize int, skipExisting bool) error {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{broker},
+		Topic:   ingestTopic,
+		GroupID: "dorsodepth-ingest-worker",
+	})
+	defer reader.Close()
+
+	fmt.Printf("Worker mode: waiting for jobs on %s\n", ingestTopic)
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to fetch job message: %w", err)
+		}
+
+		var job VideoJob
+		if err := json.Unmarshal(msg.Value, &job); err != nil {
+			log.Printf("Skipping malformed job message: %v", err)
+			if err := reader.CommitMessages(ctx, msg); err != nil {
+				log.Printf("Failed to commit malformed job message: %v", err)
+			}
+			continue
+		}
+		if job.FPS == 0 {
+			job.FPS = 1
+		}
+
+		outputDir, err := resolveJobOutputDir(job.JobID, job.OutputDir)
+		if err != nil {
+			log.Printf("Skipping job with unsafe job_id/output_dir: %v", err)
+			if err := reader.CommitMessages(ctx, msg); err != nil {
+				log.Printf("Failed to commit rejected job message: %v", err)
+			}
+			continue
+		}
+
+		fmt.Printf("Processing job %s: %s\n", job.JobID, job.VideoURI)
+		if err := runFileMode(ctx, db, store, job.VideoURI, outputDir, job.FPS, uploadConcurrency, batchSize, job.JobID, skipExisting); err != nil {
+			return fmt.Errorf("job %s failed, leaving offset uncommitted for retry: %w", job.JobID, err)
+		}
+
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			log.Printf("Failed to commit offset for job %s: %v", job.JobID, err)
+			continue
+		}
+		if err := os.RemoveAll(outputDir); err != nil {
+			log.Printf("Failed to clean up output dir for job %s: %v", job.JobID, err)
+		}
+		fmt.Printf("Completed job %s\n", job.JobID)
+	}
+}